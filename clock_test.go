@@ -0,0 +1,100 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// fakeClock is a Clock whose time only moves when Advance is called.
+type fakeClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	timers  []*fakeTimer
+	tickers []*fakeTicker
+}
+
+type fakeTimer struct {
+	at time.Time
+	c  chan time.Time
+}
+
+type fakeTicker struct {
+	clock  *fakeClock
+	period time.Duration
+	next   time.Time
+	c      chan time.Time
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	timer := &fakeTimer{at: c.now.Add(d), c: make(chan time.Time, 1)}
+	c.timers = append(c.timers, timer)
+	return timer.c
+}
+
+func (c *fakeClock) NewTicker(d time.Duration) Ticker {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ticker := &fakeTicker{clock: c, period: d, next: c.now.Add(d), c: make(chan time.Time, 1)}
+	c.tickers = append(c.tickers, ticker)
+	return ticker
+}
+
+// Advance moves the time forward by d and fires the timers and tickers that
+// fall due. Like a time.Ticker, a fake ticker drops the ticks its reader
+// falls behind on.
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+	pending := c.timers[:0]
+	for _, timer := range c.timers {
+		if timer.at.After(c.now) {
+			pending = append(pending, timer)
+			continue
+		}
+		timer.c <- c.now
+	}
+	c.timers = pending
+	for _, ticker := range c.tickers {
+		for !ticker.next.After(c.now) {
+			select {
+			case ticker.c <- ticker.next:
+			default:
+			}
+			ticker.next = ticker.next.Add(ticker.period)
+		}
+	}
+}
+
+// waiting reports how many timers and tickers are waiting for the time to
+// move.
+func (c *fakeClock) waiting() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.timers) + len(c.tickers)
+}
+
+func (t *fakeTicker) C() <-chan time.Time { return t.c }
+
+func (t *fakeTicker) Stop() {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	for i, ticker := range t.clock.tickers {
+		if ticker == t {
+			t.clock.tickers = append(t.clock.tickers[:i], t.clock.tickers[i+1:]...)
+			return
+		}
+	}
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// EmitConfig holds the settings an Emitter emits its words with.
+type EmitConfig struct {
+	// Buffer and Delay configure the channel returned by Emitter.Start, see
+	// Emit.
+	Buffer int
+	Delay  time.Duration
+	// SendTimeout, when positive, drops a word the consumer has not taken
+	// within that long and moves on to the next, see Emitter.Drops. Zero
+	// waits for the consumer however long it takes.
+	SendTimeout time.Duration
+	// SlowThreshold, when positive, logs a warning to Emitter.Logger once a
+	// send blocks longer than that waiting for the consumer. Further warnings
+	// wait for SlowInterval to pass, or never come if it is zero.
+	SlowThreshold time.Duration
+	SlowInterval  time.Duration
+	// Rate limits emission to that many words per second, evenly spaced by a
+	// ticker. Zero does not limit the rate.
+	Rate int
+	// Random picks each word at random instead of cycling in order. Every
+	// Emitter draws from its own source seeded with Seed, so a fixed Seed
+	// yields the same sequence.
+	Random bool
+	Seed   int64
+	// Cycles stops emission and closes the channel after that many passes
+	// over the word list. Zero or less emits forever.
+	Cycles int
+	// Once sends every word of the word list a single time, in order, and
+	// then closes the channel. It overrides Random and Cycles.
+	//
+	// Random, Seed, Cycles and Once only apply to a word list, not to a
+	// Source.
+	Once bool
+}
+
+// Config holds the options of a run. Its EmitConfig is used by the Emitter,
+// the output settings by drain.
+type Config struct {
+	// Count is the number of words drain writes.
+	Count int
+	// File, when set, is read for the words to emit instead of stdin or
+	// defaultWords.
+	File string
+
+	// EmitConfig holds the settings of the Emitter of a run.
+	EmitConfig
+	// WeightPairs lists word:weight pairs, such as feed:1,the:5, for
+	// Emitter.Weights, see parseWeights.
+	WeightPairs string
+
+	// DrainOnClose makes drain write the words still buffered when the run is
+	// stopped early, instead of discarding them.
+	DrainOnClose bool
+
+	// Format is either text or json, see drain. Sep separates the words in
+	// text format, and Number prefixes each with its sequence number.
+	Format string
+	Sep    string
+	Number bool
+	// Template, when set, is a text/template each word is written with in
+	// text format instead of Number, given the word as a jsonWord, such as
+	// <{{.Seq}} {{.Word}}>.
+	Template string
+	// BufferedOutput buffers the words written, which is faster for long
+	// runs but delays them until the buffer fills or the run ends.
+	BufferedOutput bool
+
+	// Duration, when positive, stops the run after that long.
+	Duration time.Duration
+	// Verbose logs every emitted word.
+	Verbose bool
+	// Serve, when set, is the address to serve the words on as server-sent
+	// events instead of writing them out.
+	Serve string
+	// List writes the resolved word list, one word per line, instead of
+	// emitting it.
+	List bool
+}
+
+// DefaultConfig returns the configuration of a run without flags.
+func DefaultConfig() Config {
+	return Config{
+		Count:  101,
+		Format: "text",
+		Sep:    "\n",
+	}
+}
+
+// Validate returns ErrNegativeCount if Count is negative, the error of
+// EmitConfig.Validate, and an error if Format is unknown or WeightPairs or
+// Template does not parse.
+func (c Config) Validate() error {
+	if err := checkCount("count", c.Count); err != nil {
+		return err
+	}
+	if err := c.EmitConfig.Validate(); err != nil {
+		return err
+	}
+	if c.Format != "text" && c.Format != "json" {
+		return fmt.Errorf("format must be text or json, got %q", c.Format)
+	}
+	if _, err := parseWeights(c.WeightPairs); err != nil {
+		return err
+	}
+	if _, err := parseTemplate(c.Template); err != nil {
+		return err
+	}
+	return nil
+}
+
+// maxRate is the highest Rate, a word every nanosecond.
+const maxRate = int(time.Second)
+
+// Validate returns ErrNegativeCount if Buffer or Rate is negative, and an
+// error if Rate is above maxRate.
+func (c EmitConfig) Validate() error {
+	if err := checkCount("buffer", c.Buffer); err != nil {
+		return err
+	}
+	if err := checkCount("rate", c.Rate); err != nil {
+		return err
+	}
+	if c.Rate > maxRate {
+		return fmt.Errorf("invalid rate %d: at most %d words per second", c.Rate, maxRate)
+	}
+	return nil
+}
+
+// parseTemplate parses s as the template of every word, or returns nil if s is
+// empty. It also executes the template once with a sample word, so that a
+// template referring to a field jsonWord lacks fails here rather than on the
+// first word written.
+func parseTemplate(s string) (*template.Template, error) {
+	if s == "" {
+		return nil, nil
+	}
+	tmpl, err := template.New("word").Parse(s)
+	if err == nil {
+		err = tmpl.Execute(io.Discard, jsonWord{Seq: 1, Word: defaultWords[0]})
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid template: %w", err)
+	}
+	return tmpl, nil
+}
+
+// parseWeights parses comma separated word:weight pairs into a map, or nil if
+// s is empty. It returns ErrNegativeCount for a negative weight.
+func parseWeights(s string) (map[string]int, error) {
+	if s == "" {
+		return nil, nil
+	}
+	weights := map[string]int{}
+	for _, pair := range strings.Split(s, ",") {
+		word, weight, ok := strings.Cut(pair, ":")
+		if !ok || word == "" {
+			return nil, fmt.Errorf("weight %q must be of the form word:weight", pair)
+		}
+		n, err := strconv.Atoi(weight)
+		if err != nil {
+			return nil, fmt.Errorf("weight of %q: %w", word, err)
+		}
+		if err := checkCount("weight", n); err != nil {
+			return nil, err
+		}
+		weights[word] = n
+	}
+	return weights, nil
+}
+
+// checkCount returns ErrNegativeCount if n, the value of the setting name,
+// is negative.
+func checkCount(name string, n int) error {
+	if n < 0 {
+		return fmt.Errorf("invalid %s %d: %w", name, n, ErrNegativeCount)
+	}
+	return nil
+}
+
+// parseConfig returns DefaultConfig updated with the command line arguments
+// args. Flag errors and usage go to stderr.
+func parseConfig(args []string) (Config, error) {
+	c := DefaultConfig()
+	flags := flag.NewFlagSet("gofums", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.IntVar(&c.Count, "count", c.Count, "number of words to print")
+	flags.IntVar(&c.Buffer, "buffer", c.Buffer, "number of words emit may send ahead of the reader")
+	flags.StringVar(&c.File, "file", c.File, "read the words to emit from this file")
+	flags.DurationVar(&c.Delay, "delay", c.Delay, "pause between emitted words, e.g. 200ms")
+	flags.BoolVar(&c.Random, "random", c.Random, "emit words in random order")
+	flags.Int64Var(&c.Seed, "seed", c.Seed, "seed for -random; 0 seeds from the clock")
+	flags.StringVar(&c.WeightPairs, "weights", c.WeightPairs, "word:weight pairs for -random, e.g. feed:1,the:5; unlisted words weigh 1")
+	flags.DurationVar(&c.SendTimeout, "send-timeout", c.SendTimeout, "drop a word the consumer does not take within this long, 0 to wait")
+	flags.DurationVar(&c.SlowThreshold, "slow-threshold", c.SlowThreshold, "warn when a send blocks longer than this, 0 to never warn")
+	flags.DurationVar(&c.SlowInterval, "slow-interval", c.SlowInterval, "warn of a slow consumer at most once per interval, 0 for only once")
+	flags.IntVar(&c.Rate, "rate", c.Rate, "emit at most this many words per second; 0 means unlimited")
+	flags.IntVar(&c.Cycles, "cycles", c.Cycles, "stop after this many passes over the words; 0 means forever")
+	flags.BoolVar(&c.Once, "once", c.Once, "emit every word a single time, in order")
+	flags.BoolVar(&c.DrainOnClose, "drain", c.DrainOnClose, "when stopped early, write the words still buffered instead of discarding them")
+	flags.StringVar(&c.Format, "format", c.Format, "output format: text or json")
+	flags.StringVar(&c.Sep, "sep", c.Sep, "separator between words in text format")
+	flags.BoolVar(&c.Number, "number", c.Number, "prefix each word with its sequence number in text format")
+	flags.StringVar(&c.Template, "template", c.Template, "text/template to write every word with in text format, such as <{{.Word}}>")
+	flags.DurationVar(&c.Duration, "duration", c.Duration, "stop emitting after this long, e.g. 2s")
+	flags.BoolVar(&c.Verbose, "verbose", c.Verbose, "log every emitted word to stderr")
+	flags.BoolVar(&c.BufferedOutput, "buffered-output", c.BufferedOutput, "buffer the output, flushing it when full and at exit")
+	flags.BoolVar(&c.List, "list", c.List, "print the words that would be emitted and exit")
+	flags.StringVar(&c.Serve, "serve", c.Serve, "serve the words as server-sent events on this address, e.g. :8080")
+	err := flags.Parse(args)
+	return c, err
+}
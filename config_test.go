@@ -0,0 +1,92 @@
+package main
+
+import (
+	"errors"
+	"maps"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDefaultConfig(t *testing.T) {
+	if err := DefaultConfig().Validate(); err != nil {
+		t.Fatalf("expected the default config to be valid, got %v", err)
+	}
+	// without flags the command line yields the default config
+	c, err := parseConfig(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c != DefaultConfig() {
+		t.Fatalf("expected %+v, got %+v", DefaultConfig(), c)
+	}
+
+	want := DefaultConfig()
+	want.Count, want.Delay, want.Random, want.Format = 5, 10*time.Millisecond, true, "json"
+	c, err = parseConfig([]string{"-count=5", "-delay=10ms", "-random", "-format=json"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c != want {
+		t.Fatalf("expected %+v, got %+v", want, c)
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(*Config)
+		err    error
+	}{
+		{"negative count", func(c *Config) { c.Count = -1 }, ErrNegativeCount},
+		{"negative buffer", func(c *Config) { c.Buffer = -1 }, ErrNegativeCount},
+		{"negative rate", func(c *Config) { c.Rate = -1 }, ErrNegativeCount},
+		{"zero count", func(c *Config) { c.Count = 0 }, nil},
+		{"negative cycles", func(c *Config) { c.Cycles = -1 }, nil},
+	}
+	for _, test := range tests {
+		c := DefaultConfig()
+		test.change(&c)
+		if err := c.Validate(); !errors.Is(err, test.err) {
+			t.Errorf("%s: expected %v, got %v", test.name, test.err, err)
+		}
+	}
+
+	c := DefaultConfig()
+	c.Format = "xml"
+	if err := c.Validate(); err == nil {
+		t.Error("expected an unknown format to be invalid")
+	}
+	c = DefaultConfig()
+	c.Rate = maxRate + 1
+	if err := c.Validate(); err == nil {
+		t.Error("expected a rate above a word per nanosecond to be invalid")
+	}
+	c.Rate = maxRate
+	if err := c.Validate(); err != nil {
+		t.Errorf("expected the highest rate to be valid, got %v", err)
+	}
+	c = DefaultConfig()
+	c.Template = "{{.Word"
+	if err := c.Validate(); err == nil || !strings.Contains(err.Error(), "invalid template") {
+		t.Errorf("expected a template parse error, got %v", err)
+	}
+}
+
+func TestParseWeights(t *testing.T) {
+	weights, err := parseWeights("feed:1,the:5,monkey:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]int{"feed": 1, "the": 5, "monkey": 0}; !maps.Equal(weights, want) {
+		t.Fatalf("expected %v, got %v", want, weights)
+	}
+	for _, s := range []string{"feed", ":1", "feed:x", "feed:1,"} {
+		if _, err := parseWeights(s); err == nil {
+			t.Errorf("%q: expected an error", s)
+		}
+	}
+	if _, err := parseWeights("feed:-1"); !errors.Is(err, ErrNegativeCount) {
+		t.Errorf("expected ErrNegativeCount, got %v", err)
+	}
+}
@@ -1,31 +1,790 @@
 package main
 
-import "fmt"
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"net/http"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"text/tabwriter"
+	"text/template"
+	"time"
+)
 
-func emit(wordChannel chan string, doneChannel chan bool) {
-	i := 0
-	words := []string{"feed", "the", "monkey"}
+var defaultWords = []string{"feed", "the", "monkey"}
+
+var (
+	// ErrNoWords is returned when there are no words to emit.
+	ErrNoWords = errors.New("no words to emit")
+	// ErrNegativeCount is returned when a count, size or rate is negative.
+	ErrNegativeCount = errors.New("negative count")
+)
+
+// emitOptions configures emit. The zero value sends every item as soon as
+// the consumer takes it, on real time.
+type emitOptions[T any] struct {
+	// clock tells the time for delay, rate, sendTimeout and slowThreshold.
+	clock Clock
+	// filter, when set, rejects the items it returns false for. A positive
+	// maxRejected ends emission once that many items in a row were rejected.
+	filter      func(T) bool
+	maxRejected int
+	// delay is waited after every send, and rate limits the sends to that
+	// many per second.
+	delay time.Duration
+	rate  int
+	// sendTimeout, when positive, gives up on an item the consumer has not
+	// taken within that long, outside of pauses, which restart it; dropped
+	// is then called with it when set.
+	sendTimeout time.Duration
+	dropped     func(T)
+	// slowThreshold, when positive, calls slow with the item and how long
+	// its send has blocked every slowThreshold the consumer keeps it
+	// waiting.
+	slowThreshold time.Duration
+	slow          func(T, time.Duration)
+	// sent, when set, is called with every item right after it was sent,
+	// along with how long the send blocked.
+	sent func(T, time.Duration)
+	// control carries pause (true) and resume (false) requests.
+	control <-chan bool
+}
+
+// newEmitOptions returns the emitOptions for the settings of c.
+func newEmitOptions[T any](c EmitConfig) emitOptions[T] {
+	return emitOptions[T]{
+		delay:         c.Delay,
+		rate:          c.Rate,
+		sendTimeout:   c.SendTimeout,
+		slowThreshold: c.SlowThreshold,
+	}
+}
+
+// emit sends the items produced by next on itemChannel, as configured by opts,
+// until next runs out or ctx is done.
+func emit[T any](ctx context.Context, next func() (T, bool), opts emitOptions[T], itemChannel chan<- T) {
+	clock := opts.clock
+	if clock == nil {
+		clock = realClock{}
+	}
+	paused := false
+	// wait blocks until ready delivers, picking up pause and resume requests
+	// meanwhile. It reports false if ctx is done first.
+	wait := func(ready <-chan time.Time) bool {
+		for {
+			select {
+			case <-ready:
+				return true
+			case paused = <-opts.control:
+			case <-ctx.Done():
+				return false
+			}
+		}
+	}
+
+	rejected := 0
+	var tick <-chan time.Time
+	if opts.rate > 0 {
+		ticker := clock.NewTicker(time.Second / time.Duration(opts.rate))
+		defer ticker.Stop()
+		tick = ticker.C()
+	}
 	for {
-		select {
-		case wordChannel <- words[i]:
-			i++
-			if i == len(words) {
-				i = 0
+		item, ok := next()
+		if !ok {
+			return
+		}
+		if opts.filter != nil && !opts.filter(item) {
+			if ctx.Err() != nil {
+				return
+			}
+			if rejected++; rejected == opts.maxRejected {
+				return
+			}
+			continue
+		}
+		rejected = 0
+		if tick != nil && !wait(tick) {
+			return
+		}
+		// The send timeout and the time blocked run from the first try to
+		// send item, leaving out pauses.
+		var timeout <-chan time.Time
+		if opts.sendTimeout > 0 && !paused {
+			timeout = clock.After(opts.sendTimeout)
+		}
+		var blocked time.Duration
+		since := clock.Now()
+	send:
+		for {
+			// a nil channel is never ready, which holds the send while paused
+			sendChannel, expired := itemChannel, timeout
+			var slowAlarm <-chan time.Time
+			if paused {
+				sendChannel, expired = nil, nil
+			} else if opts.slowThreshold > 0 && opts.slow != nil {
+				slowAlarm = clock.After(opts.slowThreshold)
+			}
+			wasPaused := paused
+			select {
+			case sendChannel <- item:
+				if opts.sent != nil {
+					opts.sent(item, blocked+clock.Now().Sub(since))
+				}
+				break send
+			case <-expired:
+				if opts.dropped != nil {
+					opts.dropped(item)
+				}
+				break send
+			case <-slowAlarm:
+				opts.slow(item, blocked+clock.Now().Sub(since))
+			case paused = <-opts.control:
+				now := clock.Now()
+				switch {
+				case paused && !wasPaused:
+					blocked += now.Sub(since)
+				case !paused && wasPaused:
+					since = now
+					if opts.sendTimeout > 0 {
+						timeout = clock.After(opts.sendTimeout)
+					}
+				}
+			case <-ctx.Done():
+				return
 			}
-		case <-doneChannel:
-			close(doneChannel)
+		}
+		if opts.delay > 0 && !wait(clock.After(opts.delay)) {
 			return
 		}
 	}
 }
-func main() {
+
+// Emit cycles through items on the returned channel until ctx is cancelled,
+// after which the channel is closed. An empty items slice sends nothing and
+// closes the channel right away.
+//
+// With a buffer of 0 every send waits for the consumer to read. A larger
+// buffer lets emit run up to buffer items ahead of the consumer; items still
+// sitting in the buffer when ctx is cancelled are never delivered. A negative
+// buffer is taken to be 0; use Emitter for ErrNegativeCount instead.
+//
+// A positive delay makes emit wait that long after each send.
+func Emit[T any](ctx context.Context, items []T, buffer int, delay time.Duration) <-chan T {
+	itemChannel := make(chan T, max(buffer, 0))
+	go func() {
+		defer close(itemChannel)
+		emit(ctx, NewSliceSource(items, 0).Next, emitOptions[T]{delay: delay}, itemChannel)
+	}()
+	return itemChannel
+}
+
+// EmitRoundRobin sends one word of each of lists in turn on the returned
+// channel until ctx is cancelled, after which the channel is closed. Every
+// list cycles through its words on its own, so shorter lists wrap sooner.
+// Empty lists are skipped; if all are empty the channel is closed right away.
+func EmitRoundRobin(ctx context.Context, lists ...[]string) <-chan string {
+	var sources []*SliceSource[string]
+	for _, list := range lists {
+		if len(list) > 0 {
+			sources = append(sources, NewSliceSource(list, 0))
+		}
+	}
+	i := 0
+	next := func() (string, bool) {
+		if len(sources) == 0 {
+			return "", false
+		}
+		word, ok := sources[i].Next()
+		i = (i + 1) % len(sources)
+		return word, ok
+	}
 	wordChannel := make(chan string)
-	doneChannel := make(chan bool)
+	go func() {
+		defer close(wordChannel)
+		emit(ctx, next, emitOptions[string]{}, wordChannel)
+	}()
+	return wordChannel
+}
+
+// Emitter sends the words of a Source, by default cycling through a word
+// list, until it is stopped.
+type Emitter struct {
+	EmitConfig
+	// Filter, when set, skips every word for which it returns false. Words
+	// that are skipped still count towards Cycles. If Filter rejects a full
+	// pass over the word list in a row the channel is closed, see
+	// wordSource; with a Source of its own the emitter cannot tell, and keeps
+	// skipping until it is stopped.
+	Filter func(string) bool
+	// Weights, when set, makes Random pick every word in proportion to its
+	// weight instead of uniformly. Words missing from Weights weigh 1.
+	Weights map[string]int
+	// Logger, when set, receives a debug record for every word sent with its
+	// sequence number and the time elapsed since Start, and the warnings of
+	// SlowThreshold.
+	Logger *slog.Logger
+	// Clock, when set, replaces real time for Delay, Rate and SendTimeout,
+	// and for the timings of SendStats and Logger.
+	Clock Clock
+
+	words       []string
+	source      Source
+	onEmit      func(word string, seq int)
+	ctx         context.Context
+	cancel      context.CancelFunc
+	wordChannel <-chan string
+	wg          sync.WaitGroup
+	// control carries pause (true) and resume (false) requests to the emit
+	// goroutine, which closes done when it returns.
+	control chan bool
+	done    chan struct{}
+	// time blocked on sends, only written by the emit goroutine
+	sendTotal atomic.Int64
+	sendMax   atomic.Int64
+	drops     atomic.Int64
+	// counts of the words sent so far, see Snapshot
+	countsMu sync.Mutex
+	counts   map[string]int
+}
+
+// NewEmitter returns an Emitter for words with the zero EmitConfig. Nothing is
+// emitted until Start is called.
+func NewEmitter(words []string) *Emitter {
+	return NewEmitterWithContext(context.Background(), words)
+}
+
+// NewEmitterWithContext returns an Emitter for words that also stops once
+// ctx is done, for instance when its deadline passes.
+func NewEmitterWithContext(ctx context.Context, words []string) *Emitter {
+	ctx, cancel := context.WithCancel(ctx)
+	return &Emitter{words: words, ctx: ctx, cancel: cancel, control: make(chan bool)}
+}
+
+// NewSourceEmitter returns an Emitter for the words of source that stops once
+// ctx is done or source runs out.
+func NewSourceEmitter(ctx context.Context, source Source) *Emitter {
+	ctx, cancel := context.WithCancel(ctx)
+	return &Emitter{source: source, ctx: ctx, cancel: cancel, control: make(chan bool)}
+}
+
+// Start launches the emit goroutine and returns its word channel. Calling
+// Start again returns the same channel.
+//
+// Start returns ErrNoWords if the emitter has neither words nor a Source,
+// and the error of EmitConfig.Validate if its settings are invalid.
+func (e *Emitter) Start() (<-chan string, error) {
+	if e.wordChannel != nil {
+		return e.wordChannel, nil
+	}
+	if e.source == nil && len(e.words) == 0 {
+		return nil, ErrNoWords
+	}
+	if err := e.EmitConfig.Validate(); err != nil {
+		return nil, err
+	}
+	source, maxRejected := e.source, 0
+	if source == nil {
+		source, maxRejected = e.wordSource()
+	}
+	clock := e.Clock
+	if clock == nil {
+		clock = realClock{}
+	}
+	opts := newEmitOptions[string](e.EmitConfig)
+	opts.clock = clock
+	opts.filter = e.Filter
+	opts.maxRejected = maxRejected
+	opts.control = e.control
+	opts.sent = e.sentHook(clock)
+	opts.dropped = func(string) { e.drops.Add(1) }
+	opts.slow = e.slowHook(clock)
+	wordChannel := make(chan string, e.Buffer)
+	e.wg.Add(1)
+	e.done = make(chan struct{})
+	go func() {
+		defer e.wg.Done()
+		defer close(e.done)
+		defer close(wordChannel)
+		emit(e.ctx, source.Next, opts, wordChannel)
+	}()
+	e.wordChannel = wordChannel
+	return wordChannel, nil
+}
+
+// sentHook returns the function emit calls after every send. It tracks the
+// time spent blocked on sends and logs the word if Logger is set.
+func (e *Emitter) sentHook(clock Clock) func(string, time.Duration) {
+	logger := e.Logger
+	if logger != nil && !logger.Enabled(e.ctx, slog.LevelDebug) {
+		logger = nil
+	}
+	onEmit, started, seq := e.onEmit, clock.Now(), 0
+	return func(word string, blocked time.Duration) {
+		seq++
+		e.countsMu.Lock()
+		if e.counts == nil {
+			e.counts = map[string]int{}
+		}
+		e.counts[word]++
+		e.countsMu.Unlock()
+		e.sendTotal.Add(int64(blocked))
+		if blocked > time.Duration(e.sendMax.Load()) {
+			e.sendMax.Store(int64(blocked))
+		}
+		if logger != nil {
+			logger.LogAttrs(e.ctx, slog.LevelDebug, "emitted",
+				slog.String("word", word), slog.Int("seq", seq), slog.Duration("elapsed", clock.Now().Sub(started)))
+		}
+		if onEmit != nil {
+			onEmit(word, seq)
+		}
+	}
+}
+
+// slowHook returns the function emit calls while a send has blocked longer
+// than SlowThreshold, or nil if there is no Logger to warn. It warns at most
+// once per SlowInterval, or only once if that is zero.
+func (e *Emitter) slowHook(clock Clock) func(string, time.Duration) {
+	if e.Logger == nil || e.SlowThreshold <= 0 {
+		return nil
+	}
+	var warned time.Time
+	return func(word string, blocked time.Duration) {
+		now := clock.Now()
+		if !warned.IsZero() && (e.SlowInterval <= 0 || now.Sub(warned) < e.SlowInterval) {
+			return
+		}
+		warned = now
+		e.Logger.LogAttrs(e.ctx, slog.LevelWarn, "consumer falling behind",
+			slog.String("word", word), slog.Duration("blocked", blocked))
+	}
+}
+
+// OnEmit registers fn to be called with every word sent and its sequence
+// number, counting from 1. It must be called before Start, and replaces any
+// function registered before.
+//
+// fn runs synchronously in the emit goroutine right after each send, so it
+// holds up emission for as long as it takes: it must not block.
+func (e *Emitter) OnEmit(fn func(word string, seq int)) {
+	e.onEmit = fn
+}
+
+// SendStats returns the total time sends have blocked waiting for the
+// consumer and the longest single block. It is safe to call while the
+// emitter runs.
+func (e *Emitter) SendStats() (total, max time.Duration) {
+	return time.Duration(e.sendTotal.Load()), time.Duration(e.sendMax.Load())
+}
+
+// Snapshot returns how many times every word was sent so far. The map is a
+// copy the caller may keep or change. It is safe to call while the emitter
+// runs.
+func (e *Emitter) Snapshot() map[string]int {
+	e.countsMu.Lock()
+	defer e.countsMu.Unlock()
+	counts := make(map[string]int, len(e.counts))
+	for word, n := range e.counts {
+		counts[word] = n
+	}
+	return counts
+}
+
+// Drops returns how many words were dropped because the consumer did not take
+// them within SendTimeout. It is safe to call while the emitter runs.
+func (e *Emitter) Drops() int {
+	return int(e.drops.Load())
+}
+
+// randomPasses is how many times the draws a random source takes to pick its
+// least likely word the filter has to reject in a row before the emitter
+// gives up. A word the filter accepts goes undrawn that long with a chance of
+// about e^-randomPasses.
+const randomPasses = 64
+
+// wordSource returns the Source for the emitter's word list, and how many
+// words in a row Filter may reject before all of them are taken to be
+// rejected: a full pass over the word list, or randomPasses of them when
+// the words are drawn at random.
+func (e *Emitter) wordSource() (Source, int) {
+	n := len(e.words)
+	if e.Once {
+		return NewSliceSource(e.words, 1), n
+	}
+	if e.Random && e.Weights != nil {
+		weights := make([]int, n)
+		var total, lightest int64
+		for i, word := range e.words {
+			weight, ok := e.Weights[word]
+			if !ok {
+				weight = 1
+			}
+			weights[i] = weight
+			if weight > 0 {
+				total += int64(weight)
+				if lightest == 0 || int64(weight) < lightest {
+					lightest = int64(weight)
+				}
+			}
+		}
+		// the lightest word is drawn once every total/lightest draws
+		passes := int64(randomPasses)
+		if lightest > 0 {
+			passes *= (total + lightest - 1) / lightest
+		}
+		return NewWeightedSource(e.words, weights, e.Seed, e.Cycles), int(min(passes, math.MaxInt32))
+	}
+	if e.Random {
+		return NewRandomSource(e.words, e.Seed, e.Cycles), randomPasses * n
+	}
+	return NewSliceSource(e.words, e.Cycles), n
+}
 
-	go emit(wordChannel, doneChannel)
+// Stop ends emission, after which the channel returned by Start is closed.
+// It is safe to call Stop more than once.
+func (e *Emitter) Stop() {
+	e.cancel()
+}
+
+// Done returns a channel that is closed once the emitter is stopped, or its
+// context is done.
+func (e *Emitter) Done() <-chan struct{} {
+	return e.ctx.Done()
+}
+
+// Pause holds emission until Resume is called. The word being emitted when
+// Pause is called is the first one sent after Resume. Pause has no effect
+// before Start or once emission has ended, and Stop still works while paused.
+func (e *Emitter) Pause() {
+	e.setPaused(true)
+}
+
+// Resume continues emission after Pause.
+func (e *Emitter) Resume() {
+	e.setPaused(false)
+}
+
+func (e *Emitter) setPaused(paused bool) {
+	if e.done == nil {
+		return
+	}
+	select {
+	case e.control <- paused:
+	case <-e.done:
+	}
+}
+
+// Wait blocks until the emit goroutine has returned. It returns immediately
+// if the emitter was never started or has already finished.
+func (e *Emitter) Wait() {
+	e.wg.Wait()
+}
+
+// scanWords returns the whitespace separated words read from r.
+func scanWords(r io.Reader) ([]string, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Split(bufio.ScanWords)
+	var words []string
+	for scanner.Scan() {
+		words = append(words, scanner.Text())
+	}
+	return words, scanner.Err()
+}
+
+// readWords returns the whitespace separated words in the file at path.
+func readWords(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	words, err := scanWords(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if len(words) == 0 {
+		return nil, fmt.Errorf("%s: no words found", path)
+	}
+	return words, nil
+}
+
+// isPiped reports whether f is a pipe or regular file rather than a terminal.
+func isPiped(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice == 0
+}
+
+// jsonWord is a word as written by drain in json format.
+type jsonWord struct {
+	Seq  int    `json:"seq"`
+	Word string `json:"word"`
+}
+
+// stats tallies the words written by drain.
+type stats struct {
+	started time.Time
+	total   int
+	counts  map[string]int
+}
+
+func newStats() *stats {
+	return &stats{started: time.Now(), counts: map[string]int{}}
+}
+
+func (s *stats) add(word string) {
+	s.total++
+	s.counts[word]++
+}
+
+// write prints the count of every distinct word, followed by the total and
+// the time passed since s was created, as aligned columns.
+func (s *stats) write(w io.Writer) error {
+	words := make([]string, 0, len(s.counts))
+	for word := range s.counts {
+		words = append(words, word)
+	}
+	slices.Sort(words)
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, word := range words {
+		fmt.Fprintf(tw, "%s\t%d\n", word, s.counts[word])
+	}
+	fmt.Fprintf(tw, "total\t%d\n", s.total)
+	fmt.Fprintf(tw, "duration\t%s\n", time.Since(s.started).Round(time.Microsecond))
+	return tw.Flush()
+}
+
+// drain writes up to c.Count words read from wordChannel to w, adding each to
+// st, and returns how many it wrote. It returns early if wordChannel is
+// closed, or once done is closed unless c.DrainOnClose is set: then it keeps
+// writing the words left in the channel buffer until the emitter closes it.
+//
+// The text format writes c.Sep between words and ends the output with a
+// newline. With c.Number each word is written as "seq: word", and with
+// c.Template as that template executed with its jsonWord instead. The json
+// format writes one jsonWord per line, and ignores c.Sep, c.Number and
+// c.Template. Seq counts from 1 throughout.
+//
+// With c.BufferedOutput the words are buffered on their way to w, which is
+// flushed before drain returns, whichever way it does.
+func drain(w io.Writer, wordChannel <-chan string, done <-chan struct{}, c Config, st *stats) (n int, err error) {
+	var tmpl *template.Template
+	if c.Format != "json" {
+		if tmpl, err = parseTemplate(c.Template); err != nil {
+			return 0, err
+		}
+	}
+	if c.BufferedOutput {
+		buffered := bufio.NewWriter(w)
+		defer func() {
+			if flushErr := buffered.Flush(); err == nil {
+				err = flushErr
+			}
+		}()
+		w = buffered
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+read:
+	for n < c.Count {
+		select {
+		case <-done:
+			if !c.DrainOnClose {
+				break read
+			}
+		default:
+		}
+		word, ok := <-wordChannel
+		if !ok {
+			break
+		}
+		var err error
+		text := word
+		switch {
+		case tmpl != nil:
+			var b strings.Builder
+			if err = tmpl.Execute(&b, jsonWord{Seq: n + 1, Word: word}); err != nil {
+				return n, err
+			}
+			text = b.String()
+		case c.Number:
+			text = fmt.Sprintf("%d: %s", n+1, word)
+		}
+		switch {
+		case c.Format == "json":
+			err = encoder.Encode(jsonWord{Seq: n + 1, Word: word})
+		case n == 0:
+			_, err = io.WriteString(w, text)
+		default:
+			_, err = io.WriteString(w, c.Sep+text)
+		}
+		if err != nil {
+			return n, err
+		}
+		st.add(word)
+		n++
+	}
+	if c.Format != "json" && n > 0 {
+		if _, err := io.WriteString(w, "\n"); err != nil {
+			return n, err
+		}
+	}
+	return n, nil
+}
+
+// Exit codes returned by run.
+const (
+	exitOK          = 0
+	exitError       = 1
+	exitUsage       = 2
+	exitInterrupted = 3
+)
+
+// The streams used by run, replaced in tests.
+var (
+	stdin  *os.File  = os.Stdin
+	stdout io.Writer = os.Stdout
+	stderr io.Writer = os.Stderr
+)
+
+// run executes the program with the command line arguments args and returns
+// its exit code: exitOK once the words were emitted, exitInterrupted when
+// stopped by a signal, exitUsage for invalid configuration and exitError for
+// any other failure.
+func run(args []string) int {
+	c, err := parseConfig(args)
+	if err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK
+		}
+		return exitUsage
+	}
+	if err := c.Validate(); err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitUsage
+	}
+	// Validate made sure the weights parse
+	weights, _ := parseWeights(c.WeightPairs)
+
+	words := defaultWords
+	if c.File != "" {
+		var err error
+		words, err = readWords(c.File)
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return exitError
+		}
+	} else if isPiped(stdin) {
+		var err error
+		words, err = scanWords(stdin)
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return exitError
+		}
+		if len(words) == 0 {
+			return exitOK
+		}
+	}
+	if c.List {
+		for _, word := range words {
+			if _, err := fmt.Fprintln(stdout, word); err != nil {
+				fmt.Fprintln(stderr, err)
+				return exitError
+			}
+		}
+		return exitOK
+	}
+
+	newEmitter := func(ctx context.Context) *Emitter {
+		emitter := NewEmitterWithContext(ctx, words)
+		emitter.EmitConfig = c.EmitConfig
+		if c.Seed == 0 {
+			// every emitter of -serve picks its own random sequence
+			emitter.Seed = time.Now().UnixNano()
+		}
+		emitter.Weights = weights
+		switch {
+		case c.Verbose:
+			emitter.Logger = slog.New(slog.NewTextHandler(stderr, &slog.HandlerOptions{Level: slog.LevelDebug}))
+		case c.SlowThreshold > 0:
+			emitter.Logger = slog.New(slog.NewTextHandler(stderr, &slog.HandlerOptions{Level: slog.LevelWarn}))
+		}
+		return emitter
+	}
+
+	if c.Serve != "" {
+		err := http.ListenAndServe(c.Serve, newServeMux(newEmitter))
+		fmt.Fprintln(stderr, err)
+		return exitError
+	}
 
-	for i := 0; i <= 100; i++ {
-		fmt.Println(<-wordChannel)
+	ctx := context.Background()
+	if c.Duration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.Duration)
+		defer cancel()
 	}
-	doneChannel <- true
+	emitter := newEmitter(ctx)
+	st := newStats()
+	wordChannel, err := emitter.Start()
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitUsage
+	}
+
+	// A signal or the -duration deadline stops the emitter just like reaching
+	// count does: the emitter closes wordChannel, which ends drain early.
+	signalChannel := make(chan os.Signal, 1)
+	signal.Notify(signalChannel, os.Interrupt, syscall.SIGTERM)
+	drained := make(chan struct{})
+	var interrupted atomic.Bool
+	go func() {
+		select {
+		case <-signalChannel:
+			interrupted.Store(true)
+			emitter.Stop()
+		case <-drained:
+		}
+	}()
+
+	n, err := drain(stdout, wordChannel, emitter.Done(), c, st)
+	close(drained)
+	signal.Stop(signalChannel)
+	emitter.Stop()
+	// Never return while the producer is still running. Any buffered words
+	// drain left over are discarded with the channel.
+	emitter.Wait()
+	code := exitOK
+	if interrupted.Load() {
+		fmt.Fprintf(stderr, "interrupted after %d words\n", n)
+		code = exitInterrupted
+	} else if ctx.Err() == context.DeadlineExceeded {
+		fmt.Fprintf(stderr, "stopped after %s with %d words\n", c.Duration, n)
+	}
+	st.write(stderr)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitError
+	}
+	return code
+}
+
+func main() {
+	os.Exit(run(os.Args[1:]))
 }
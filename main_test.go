@@ -0,0 +1,1184 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// testTimeout bounds every wait in the tests so a hung goroutine fails the
+// test instead of hanging it.
+const testTimeout = time.Second
+
+// startEmitter starts an emitter for words and stops it when the test ends.
+func startEmitter(t *testing.T, words []string) (*Emitter, <-chan string) {
+	t.Helper()
+	emitter := NewEmitter(words)
+	wordChannel, err := emitter.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		emitter.Stop()
+		emitter.Wait()
+	})
+	return emitter, wordChannel
+}
+
+// receive reads n words from wordChannel, failing if any takes longer than
+// testTimeout or the channel is closed early.
+func receive(t *testing.T, wordChannel <-chan string, n int) []string {
+	t.Helper()
+	words := make([]string, 0, n)
+	for len(words) < n {
+		select {
+		case word, ok := <-wordChannel:
+			if !ok {
+				t.Fatalf("channel closed after %d of %d words", len(words), n)
+			}
+			words = append(words, word)
+		case <-time.After(testTimeout):
+			t.Fatalf("timed out after %d of %d words", len(words), n)
+		}
+	}
+	return words
+}
+
+// waitClosed fails unless wordChannel is closed within testTimeout. Words still
+// in flight are discarded.
+func waitClosed(t *testing.T, wordChannel <-chan string) {
+	t.Helper()
+	timeout := time.After(testTimeout)
+	for {
+		select {
+		case _, ok := <-wordChannel:
+			if !ok {
+				return
+			}
+		case <-timeout:
+			t.Fatal("channel was not closed")
+		}
+	}
+}
+
+func TestEmitterCycles(t *testing.T) {
+	_, wordChannel := startEmitter(t, defaultWords)
+	got := Collect(wordChannel, 7)
+	want := []string{"feed", "the", "monkey", "feed", "the", "monkey", "feed"}
+	if !slices.Equal(got, want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+}
+
+func TestEmitterStop(t *testing.T) {
+	emitter, wordChannel := startEmitter(t, defaultWords)
+	receive(t, wordChannel, 2)
+	emitter.Stop()
+	waitClosed(t, wordChannel)
+
+	done := make(chan struct{})
+	go func() {
+		emitter.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(testTimeout):
+		t.Fatal("emit goroutine did not return after Stop")
+	}
+}
+
+func TestEmitterTwoWords(t *testing.T) {
+	_, wordChannel := startEmitter(t, []string{"feed", "monkey"})
+	want := []string{"feed", "monkey", "feed", "monkey", "feed", "monkey", "feed"}
+	if got := receive(t, wordChannel, 7); !slices.Equal(got, want) {
+		t.Fatalf("expected %q, got %q", want, got)
+	}
+}
+
+func TestEmitterStopTwice(t *testing.T) {
+	emitter, wordChannel := startEmitter(t, defaultWords)
+	receive(t, wordChannel, 1)
+	emitter.Stop()
+	emitter.Stop()
+	waitClosed(t, wordChannel)
+	emitter.Wait()
+	emitter.Stop()
+}
+
+func TestEmitterStopUnread(t *testing.T) {
+	emitter, wordChannel := startEmitter(t, defaultWords)
+	// stopping must not wait for a consumer that never reads
+	emitter.Stop()
+	emitter.Wait()
+	waitClosed(t, wordChannel)
+}
+
+func TestEmitterWait(t *testing.T) {
+	before := runtime.NumGoroutine()
+	for i := 0; i < 20; i++ {
+		emitter := NewEmitter(defaultWords)
+		emitter.Cycles = 1
+		wordChannel, err := emitter.Start()
+		if err != nil {
+			t.Fatal(err)
+		}
+		receive(t, wordChannel, 3)
+		waitClosed(t, wordChannel)
+		// the goroutine has returned by now; Wait must not block on it, nor
+		// when it is called again
+		emitter.Wait()
+		emitter.Stop()
+		emitter.Wait()
+	}
+	// every emit goroutine is gone once Wait returned
+	if after := runtime.NumGoroutine(); after > before {
+		t.Fatalf("expected at most %d goroutines, got %d", before, after)
+	}
+}
+
+// TestEmitterShutdown stops emitters concurrently with the consumer, after it
+// read some words, or before it read any. Run it with -race.
+func TestEmitterShutdown(t *testing.T) {
+	for i := 0; i < 100; i++ {
+		emitter := NewEmitter(defaultWords)
+		emitter.Buffer = i % 3
+		wordChannel, err := emitter.Start()
+		if err != nil {
+			t.Fatal(err)
+		}
+		switch i % 3 {
+		case 0:
+			go emitter.Stop()
+		case 1:
+			receive(t, wordChannel, 2)
+			emitter.Stop()
+		}
+		emitter.Stop()
+		waitClosed(t, wordChannel)
+		emitter.Wait()
+	}
+
+	for i := 0; i < 20; i++ {
+		out, _ := redirect(t)
+		if code := run([]string{"-count=2", "-buffer=2"}); code != exitOK || out.String() != "feed\nthe\n" {
+			t.Fatalf("run %d: expected exit code %d and two words, got %d and %q", i, exitOK, code, out.String())
+		}
+	}
+}
+
+func TestSourceEmitter(t *testing.T) {
+	emitter := NewSourceEmitter(context.Background(), NewSliceSource(defaultWords, 1))
+	wordChannel, err := emitter.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the channel closes once the source runs out
+	if got := Collect(wordChannel, 5); !slices.Equal(got, defaultWords) {
+		t.Fatalf("expected %v, got %v", defaultWords, got)
+	}
+	emitter.Wait()
+}
+
+func TestEmitterLogger(t *testing.T) {
+	var logs bytes.Buffer
+	emitter := NewEmitter(defaultWords)
+	emitter.Cycles = 2
+	emitter.Logger = slog.New(slog.NewJSONHandler(&logs, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	wordChannel, err := emitter.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for range wordChannel {
+	}
+	emitter.Wait()
+
+	type record struct {
+		Msg  string
+		Word string
+		Seq  int
+	}
+	var records []record
+	decoder := json.NewDecoder(&logs)
+	for decoder.More() {
+		var record record
+		if err := decoder.Decode(&record); err != nil {
+			t.Fatal(err)
+		}
+		records = append(records, record)
+	}
+	if len(records) != 6 {
+		t.Fatalf("expected 6 records, got %d", len(records))
+	}
+	for i, record := range records {
+		if record.Msg != "emitted" || record.Seq != i+1 || record.Word != defaultWords[i%3] {
+			t.Errorf("unexpected record %d: %+v", i, record)
+		}
+	}
+}
+
+func TestEmitterOnEmit(t *testing.T) {
+	var words []string
+	emitter := NewEmitter(defaultWords)
+	emitter.Cycles = 2
+	emitter.OnEmit(func(word string, seq int) {
+		if seq != len(words)+1 {
+			t.Errorf("word %q: expected seq %d, got %d", word, len(words)+1, seq)
+		}
+		words = append(words, word)
+	})
+	wordChannel, err := emitter.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	received := Collect(wordChannel, 10)
+	emitter.Wait()
+
+	if len(received) != 6 || !slices.Equal(words, received) {
+		t.Errorf("expected callbacks for %q, got %q", received, words)
+	}
+}
+
+func TestEmitterSendStats(t *testing.T) {
+	emitter, wordChannel := startEmitter(t, defaultWords)
+	for i := 0; i < 5; i++ {
+		// a slow consumer keeps emit waiting on every send
+		time.Sleep(5 * time.Millisecond)
+		receive(t, wordChannel, 1)
+	}
+	total, max := emitter.SendStats()
+	if total < 15*time.Millisecond || max < 4*time.Millisecond || max > total {
+		t.Fatalf("expected sends to block around 5ms each, got total %s and max %s", total, max)
+	}
+}
+
+func TestEmitterSnapshot(t *testing.T) {
+	emitter, wordChannel := startEmitter(t, defaultWords)
+	if counts := emitter.Snapshot(); len(counts) != 0 {
+		t.Fatalf("expected no counts before the first word, got %v", counts)
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 300; i++ {
+			<-wordChannel
+		}
+	}()
+poll:
+	for {
+		select {
+		case <-done:
+			break poll
+		default:
+		}
+		// changes to a snapshot must not reach the emitter
+		emitter.Snapshot()["feed"] = -1000
+	}
+	// the last word read may be counted only after the consumer has it
+	emitter.Stop()
+	emitter.Wait()
+
+	counts := emitter.Snapshot()
+	for _, word := range defaultWords {
+		if counts[word] < 100 {
+			t.Errorf("expected %q at least 100 times, got %d", word, counts[word])
+		}
+	}
+}
+
+func TestEmitterSendTimeout(t *testing.T) {
+	emitter := NewEmitter(defaultWords)
+	emitter.SendTimeout = time.Millisecond
+	wordChannel, err := emitter.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		emitter.Stop()
+		emitter.Wait()
+	}()
+	receive(t, wordChannel, 1)
+	// the consumer goes away for a while
+	time.Sleep(20 * time.Millisecond)
+	if drops := emitter.Drops(); drops == 0 {
+		t.Fatal("expected words to be dropped while the consumer was away")
+	}
+	// emission carries on once the consumer is back
+	receive(t, wordChannel, 3)
+}
+
+func TestEmitterSlowConsumer(t *testing.T) {
+	var logs syncBuffer
+	emitter := NewEmitter(defaultWords)
+	emitter.SlowThreshold = 2 * time.Millisecond
+	emitter.SlowInterval = time.Hour
+	emitter.Logger = slog.New(slog.NewTextHandler(&logs, &slog.HandlerOptions{Level: slog.LevelWarn}))
+	wordChannel, err := emitter.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 5; i++ {
+		// a slow consumer keeps emit waiting on every send
+		time.Sleep(5 * time.Millisecond)
+		receive(t, wordChannel, 1)
+	}
+	emitter.Stop()
+	emitter.Wait()
+
+	if n := strings.Count(logs.String(), "consumer falling behind"); n != 1 {
+		t.Fatalf("expected a single warning, got %d:\n%s", n, logs.String())
+	}
+}
+
+func TestEmitterStalledConsumer(t *testing.T) {
+	var logs syncBuffer
+	emitter := NewEmitter(defaultWords)
+	emitter.SlowThreshold = 2 * time.Millisecond
+	emitter.SlowInterval = time.Hour
+	emitter.Logger = slog.New(slog.NewTextHandler(&logs, &slog.HandlerOptions{Level: slog.LevelWarn}))
+	wordChannel, err := emitter.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	receive(t, wordChannel, 1)
+	// the consumer stops reading altogether, so no send completes
+	time.Sleep(100 * time.Millisecond)
+	emitter.Stop()
+	emitter.Wait()
+
+	if n := strings.Count(logs.String(), "consumer falling behind"); n != 1 {
+		t.Fatalf("expected a single warning, got %d:\n%s", n, logs.String())
+	}
+}
+
+func TestEmitterSendTimeoutSlow(t *testing.T) {
+	var logs syncBuffer
+	emitter := NewEmitter(defaultWords)
+	emitter.SendTimeout = 20 * time.Millisecond
+	emitter.SlowThreshold = 5 * time.Millisecond
+	emitter.Logger = slog.New(slog.NewTextHandler(&logs, &slog.HandlerOptions{Level: slog.LevelWarn}))
+	wordChannel, err := emitter.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		emitter.Stop()
+		emitter.Wait()
+	}()
+	// the stats of the last word read may only be recorded after the read
+	for i := 0; i < 4; i++ {
+		// a slow consumer, but quick enough for every word to make it
+		time.Sleep(12 * time.Millisecond)
+		receive(t, wordChannel, 1)
+	}
+	// the slow warnings neither restart the timeout nor the blocked time
+	if total, max := emitter.SendStats(); total < 30*time.Millisecond || max < 10*time.Millisecond {
+		t.Errorf("expected sends to block around 12ms each, got total %s and max %s", total, max)
+	}
+	if drops := emitter.Drops(); drops != 0 {
+		t.Errorf("expected no drops, got %d", drops)
+	}
+
+	// the consumer goes away for longer than the timeout, several times over
+	time.Sleep(100 * time.Millisecond)
+	if drops := emitter.Drops(); drops < 2 {
+		t.Errorf("expected words to be dropped while the consumer was away, got %d drops", drops)
+	}
+	if !strings.Contains(logs.String(), "consumer falling behind") {
+		t.Errorf("expected a warning, got:\n%s", logs.String())
+	}
+}
+
+func TestEmitterOnce(t *testing.T) {
+	tests := []struct {
+		filter func(string) bool
+		out    []string
+	}{
+		{nil, defaultWords},
+		// skipped words are used up by the single pass all the same
+		{func(word string) bool { return len(word) > 3 }, []string{"feed", "monkey"}},
+		{func(word string) bool { return false }, []string{}},
+	}
+	for _, test := range tests {
+		emitter := NewEmitter(defaultWords)
+		emitter.Once = true
+		emitter.Random = true
+		emitter.Filter = test.filter
+		wordChannel, err := emitter.Start()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := Collect(wordChannel, 10); !slices.Equal(got, test.out) {
+			t.Errorf("expected %v, got %v", test.out, got)
+		}
+		emitter.Wait()
+	}
+}
+
+func TestEmitterFilter(t *testing.T) {
+	emitter := NewEmitter(defaultWords)
+	emitter.Filter = func(word string) bool { return len(word) > 3 }
+	wordChannel, err := emitter.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"feed", "monkey", "feed", "monkey", "feed"}
+	if got := receive(t, wordChannel, 5); !slices.Equal(got, want) {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+	emitter.Stop()
+	emitter.Wait()
+
+	// cycling through words that are all rejected must end, not spin
+	emitter = NewEmitter(defaultWords)
+	emitter.Filter = func(string) bool { return false }
+	wordChannel, err = emitter.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitClosed(t, wordChannel)
+	emitter.Wait()
+
+	// drawing at random, rejected words do not close the channel early
+	emitter = NewEmitter(defaultWords)
+	emitter.Random = true
+	emitter.Weights = map[string]int{"feed": 20}
+	emitter.Filter = func(word string) bool { return word == "monkey" }
+	wordChannel, err = emitter.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, word := range receive(t, wordChannel, 100) {
+		if word != "monkey" {
+			t.Fatalf("expected only monkey, got %q", word)
+		}
+	}
+	emitter.Stop()
+	emitter.Wait()
+
+	// the only word the filter accepts is never drawn
+	emitter = NewEmitter(defaultWords)
+	emitter.Random = true
+	emitter.Weights = map[string]int{"feed": 0}
+	emitter.Filter = func(word string) bool { return word == "feed" }
+	wordChannel, err = emitter.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitClosed(t, wordChannel)
+	emitter.Wait()
+
+	// a Source may yet produce a word the filter accepts, but Stop ends it
+	emitter = NewSourceEmitter(context.Background(), NewSliceSource(defaultWords, 0))
+	emitter.Filter = func(string) bool { return false }
+	wordChannel, err = emitter.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	emitter.Stop()
+	waitClosed(t, wordChannel)
+	emitter.Wait()
+}
+
+// silent fails if wordChannel delivers a word within a short while.
+func silent(t *testing.T, wordChannel <-chan string) {
+	t.Helper()
+	select {
+	case word := <-wordChannel:
+		t.Fatalf("expected no words, got %q", word)
+	case <-time.After(20 * time.Millisecond):
+	}
+}
+
+func TestEmitterPauseStop(t *testing.T) {
+	emitter, wordChannel := startEmitter(t, defaultWords)
+	receive(t, wordChannel, 1)
+	emitter.Pause()
+	silent(t, wordChannel)
+	emitter.Stop()
+	waitClosed(t, wordChannel)
+}
+
+func TestEmitterPauseResume(t *testing.T) {
+	emitter, wordChannel := startEmitter(t, defaultWords)
+	receive(t, wordChannel, 1)
+	emitter.Pause()
+	silent(t, wordChannel)
+	emitter.Resume()
+	got := receive(t, wordChannel, 3)
+	if want := []string{"the", "monkey", "feed"}; !slices.Equal(got, want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+}
+
+func TestEmitterStartErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		words  []string
+		buffer int
+		rate   int
+		err    error
+	}{
+		{"no words", nil, 0, 0, ErrNoWords},
+		{"empty words", []string{}, 0, 0, ErrNoWords},
+		{"negative buffer", defaultWords, -1, 0, ErrNegativeCount},
+		{"negative rate", defaultWords, 0, -1, ErrNegativeCount},
+	}
+	for _, test := range tests {
+		emitter := NewEmitter(test.words)
+		emitter.Buffer = test.buffer
+		emitter.Rate = test.rate
+		if _, err := emitter.Start(); !errors.Is(err, test.err) {
+			t.Errorf("%s: expected %v, got %v", test.name, test.err, err)
+		}
+		emitter.Stop()
+		emitter.Wait()
+	}
+
+	// a rate the ticker cannot keep up with fails Start, not emit
+	emitter := NewEmitter(defaultWords)
+	emitter.Rate = 2_000_000_000
+	if _, err := emitter.Start(); err == nil {
+		t.Error("expected a rate of 2e9 words per second to be invalid")
+	}
+	emitter.Stop()
+	emitter.Wait()
+}
+
+func TestEmitterDeadline(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	emitter := NewEmitterWithContext(ctx, defaultWords)
+	emitter.Delay = time.Millisecond
+	start := time.Now()
+	wordChannel, err := emitter.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitClosed(t, wordChannel)
+	emitter.Wait()
+	if elapsed := time.Since(start); elapsed > 250*time.Millisecond {
+		t.Fatalf("expected the emitter to stop after 50ms, took %s", elapsed)
+	}
+}
+
+func TestEmitterRate(t *testing.T) {
+	emitter := NewEmitter(defaultWords)
+	emitter.Rate = 100
+	wordChannel, err := emitter.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		emitter.Stop()
+		emitter.Wait()
+	}()
+
+	window := time.After(200 * time.Millisecond)
+	n := 0
+count:
+	for {
+		select {
+		case <-wordChannel:
+			n++
+		case <-window:
+			break count
+		}
+	}
+	// 100 words per second for 200ms, with room for a slow scheduler
+	if n < 10 || n > 21 {
+		t.Fatalf("expected about 20 words, got %d", n)
+	}
+}
+
+func TestEmitterDelay(t *testing.T) {
+	const delay = 50 * time.Millisecond
+	clock := newFakeClock()
+	emitter := NewEmitter(defaultWords)
+	emitter.Delay = delay
+	emitter.Clock = clock
+	wordChannel, err := emitter.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		emitter.Stop()
+		emitter.Wait()
+	}()
+	receive(t, wordChannel, 1)
+
+	for i := 1; i < 5; i++ {
+		// emit waits for the delay after every send
+		for clock.waiting() == 0 {
+			runtime.Gosched()
+		}
+		clock.Advance(delay - time.Millisecond)
+		select {
+		case word := <-wordChannel:
+			t.Fatalf("word %d: got %q before the delay passed", i, word)
+		default:
+		}
+		clock.Advance(time.Millisecond)
+		receive(t, wordChannel, 1)
+	}
+
+	// and in real time, 5 words take 4 delays
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	itemChannel := Emit(ctx, defaultWords, 0, 10*time.Millisecond)
+	started := time.Now()
+	receive(t, itemChannel, 5)
+	if elapsed := time.Since(started); elapsed < 40*time.Millisecond || elapsed > 200*time.Millisecond {
+		t.Fatalf("expected 5 words to take about 40ms, took %s", elapsed)
+	}
+}
+
+func TestEmitterRateFakeClock(t *testing.T) {
+	clock := newFakeClock()
+	emitter := NewEmitter(defaultWords)
+	emitter.Rate = 10
+	emitter.Clock = clock
+	wordChannel, err := emitter.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		emitter.Stop()
+		emitter.Wait()
+	}()
+	for clock.waiting() == 0 {
+		runtime.Gosched()
+	}
+
+	for i := 0; i < 6; i++ {
+		select {
+		case word := <-wordChannel:
+			t.Fatalf("tick %d: got %q before the tick", i, word)
+		default:
+		}
+		// 10 words per second are 100ms apart
+		clock.Advance(99 * time.Millisecond)
+		select {
+		case word := <-wordChannel:
+			t.Fatalf("tick %d: got %q before the tick", i, word)
+		default:
+		}
+		clock.Advance(time.Millisecond)
+		if words := receive(t, wordChannel, 1); words[0] != defaultWords[i%3] {
+			t.Errorf("tick %d: expected %q, got %q", i, defaultWords[i%3], words[0])
+		}
+	}
+}
+
+func TestDrain(t *testing.T) {
+	tests := []struct {
+		sep   string
+		count int
+		out   string
+	}{
+		{"\n", 4, "feed\nthe\nmonkey\nfeed\n"},
+		{" ", 4, "feed the monkey feed\n"},
+		{", ", 3, "feed, the, monkey\n"},
+		{" -- ", 1, "feed\n"},
+		{" ", 0, ""},
+	}
+	for _, test := range tests {
+		emitter, wordChannel := startEmitter(t, defaultWords)
+		c := DefaultConfig()
+		c.Count, c.Sep = test.count, test.sep
+		var out bytes.Buffer
+		n, err := drain(&out, wordChannel, emitter.Done(), c, newStats())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != test.count || out.String() != test.out {
+			t.Errorf("sep %q: expected %d words %q, got %d words %q", test.sep, test.count, test.out, n, out.String())
+		}
+	}
+}
+
+func TestDrainJSON(t *testing.T) {
+	words := []string{`"quoted"`, "<b>&amp;", "café"}
+	emitter, wordChannel := startEmitter(t, words)
+	c := DefaultConfig()
+	c.Count, c.Format = 5, "json"
+	var out bytes.Buffer
+	if _, err := drain(&out, wordChannel, emitter.Done(), c, newStats()); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+	if len(lines) != c.Count {
+		t.Fatalf("expected %d lines, got %q", c.Count, out.String())
+	}
+	for i, line := range lines {
+		var got jsonWord
+		if err := json.Unmarshal([]byte(line), &got); err != nil {
+			t.Fatalf("line %d %q: %v", i, line, err)
+		}
+		if want := (jsonWord{Seq: i + 1, Word: words[i%3]}); got != want {
+			t.Errorf("line %d: expected %+v, got %+v", i, want, got)
+		}
+	}
+}
+
+func TestDrainStats(t *testing.T) {
+	emitter, wordChannel := startEmitter(t, defaultWords)
+	c := DefaultConfig()
+	c.Count = 10
+	st := newStats()
+	if _, err := drain(io.Discard, wordChannel, emitter.Done(), c, st); err != nil {
+		t.Fatal(err)
+	}
+	sum := 0
+	for _, n := range st.counts {
+		sum += n
+	}
+	if st.total != c.Count || sum != st.total {
+		t.Fatalf("expected counts adding up to %d, got total %d and counts %v", c.Count, st.total, st.counts)
+	}
+	if want := map[string]int{"feed": 4, "the": 3, "monkey": 3}; !maps.Equal(st.counts, want) {
+		t.Fatalf("expected counts %v, got %v", want, st.counts)
+	}
+
+	var out bytes.Buffer
+	if err := st.write(&out); err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{"feed      4\n", "monkey    3\n", "the       3\n", "total     10\n"} {
+		if !strings.Contains(out.String(), line) {
+			t.Errorf("expected %q in the stats, got:\n%s", line, out.String())
+		}
+	}
+}
+
+func TestDrainOnClose(t *testing.T) {
+	for _, drainOnClose := range []bool{false, true} {
+		emitter := NewEmitter(defaultWords)
+		emitter.Buffer = 5
+		wordChannel, err := emitter.Start()
+		if err != nil {
+			t.Fatal(err)
+		}
+		receive(t, wordChannel, 1)
+		// let emit fill the buffer, then stop it mid-stream
+		for len(wordChannel) < 5 {
+			time.Sleep(time.Millisecond)
+		}
+		emitter.Stop()
+		emitter.Wait()
+
+		c := DefaultConfig()
+		c.DrainOnClose = drainOnClose
+		var out bytes.Buffer
+		n, err := drain(&out, wordChannel, emitter.Done(), c, newStats())
+		if err != nil {
+			t.Fatal(err)
+		}
+		want, text := 0, ""
+		if drainOnClose {
+			want, text = 5, "the\nmonkey\nfeed\nthe\nmonkey\n"
+		}
+		if n != want || out.String() != text {
+			t.Errorf("drain on close %t: expected %d words %q, got %d words %q", drainOnClose, want, text, n, out.String())
+		}
+	}
+}
+
+func TestDrainBufferedOutput(t *testing.T) {
+	emitter := NewEmitter(defaultWords)
+	emitter.OnEmit(func(word string, seq int) {
+		// cut the run short well before Count
+		if seq == 50 {
+			emitter.Stop()
+		}
+	})
+	wordChannel, err := emitter.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer emitter.Wait()
+
+	c := DefaultConfig()
+	c.Count = 1000
+	c.BufferedOutput = true
+	var out bytes.Buffer
+	n, err := drain(&out, wordChannel, emitter.Done(), c, newStats())
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+	if n == 0 || n >= c.Count || len(lines) != n {
+		t.Fatalf("expected all of the %d words drained to be written, got %d lines", n, len(lines))
+	}
+	for i, line := range lines {
+		if line != defaultWords[i%3] {
+			t.Fatalf("line %d: expected %q, got %q", i, defaultWords[i%3], line)
+		}
+	}
+}
+
+// syncBuffer is a bytes.Buffer that is safe to read while run writes to it.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+// redirect points the streams used by run at an empty stdin and the returned
+// buffers for the rest of the test.
+func redirect(t *testing.T) (out, errOut *syncBuffer) {
+	t.Helper()
+	devNull, err := os.Open(os.DevNull)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, errOut = &syncBuffer{}, &syncBuffer{}
+	oldStdin, oldStdout, oldStderr := stdin, stdout, stderr
+	stdin, stdout, stderr = devNull, out, errOut
+	t.Cleanup(func() {
+		stdin, stdout, stderr = oldStdin, oldStdout, oldStderr
+		devNull.Close()
+	})
+	return out, errOut
+}
+
+func TestReadWords(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "words.txt")
+	if err := os.WriteFile(path, []byte("feed  the\n\n   monkey\tsome\nbananas\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	words, err := readWords(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"feed", "the", "monkey", "some", "bananas"}; !slices.Equal(words, want) {
+		t.Fatalf("expected %q, got %q", want, words)
+	}
+
+	for _, content := range []string{"", " \n\t \n"} {
+		path := filepath.Join(dir, "blank.txt")
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if words, err := readWords(path); err == nil {
+			t.Errorf("%q: expected an error, got %q", content, words)
+		}
+	}
+}
+
+func TestScanWords(t *testing.T) {
+	tests := []struct {
+		in  string
+		out []string
+	}{
+		{"feed the monkey", []string{"feed", "the", "monkey"}},
+		{"  feed\n\tthe   monkey \n", []string{"feed", "the", "monkey"}},
+		{"", nil},
+		{" \n ", nil},
+	}
+	for _, test := range tests {
+		words, err := scanWords(strings.NewReader(test.in))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !slices.Equal(words, test.out) {
+			t.Errorf("%q: expected %q, got %q", test.in, test.out, words)
+		}
+	}
+}
+
+func TestRun(t *testing.T) {
+	const json2 = `{"seq":1,"word":"feed"}` + "\n" + `{"seq":2,"word":"the"}` + "\n"
+	tests := []struct {
+		args []string
+		code int
+		out  string
+	}{
+		{[]string{"-count=3"}, exitOK, "feed\nthe\nmonkey\n"},
+		{[]string{"-count=0"}, exitOK, ""},
+		{[]string{"-cycles=1", "-count=10"}, exitOK, "feed\nthe\nmonkey\n"},
+		{[]string{"-number", "-count=4"}, exitOK, "1: feed\n2: the\n3: monkey\n4: feed\n"},
+		{[]string{"-number", "-sep= ", "-count=2"}, exitOK, "1: feed 2: the\n"},
+		{[]string{"-format=json", "-count=2"}, exitOK, json2},
+		{[]string{"-number", "-format=json", "-count=2"}, exitOK, json2},
+		{[]string{"-template=<{{.Seq}} {{.Word}}>", "-sep= ", "-count=3"}, exitOK, "<1 feed> <2 the> <3 monkey>\n"},
+		{[]string{"-template=<{{.Word}}>", "-number", "-count=2"}, exitOK, "<feed>\n<the>\n"},
+		{[]string{"-template=<{{.Word}>", "-count=2"}, exitUsage, ""},
+		{[]string{"-template={{.Foo}}", "-count=2"}, exitUsage, ""},
+		{[]string{"-template={{.Foo}}", "-format=json", "-count=2"}, exitUsage, ""},
+		{[]string{"-template=<{{.Word}}>", "-format=json", "-count=2"}, exitOK, json2},
+		{[]string{"-buffered-output", "-count=4"}, exitOK, "feed\nthe\nmonkey\nfeed\n"},
+		{[]string{"-count=-1"}, exitUsage, ""},
+		{[]string{"-buffer=-1"}, exitUsage, ""},
+		{[]string{"-format=xml"}, exitUsage, ""},
+		{[]string{"-no-such-flag"}, exitUsage, ""},
+		{[]string{"-file=" + filepath.Join(t.TempDir(), "missing.txt")}, exitError, ""},
+	}
+	for _, test := range tests {
+		out, _ := redirect(t)
+		if code := run(test.args); code != test.code {
+			t.Errorf("%v: expected exit code %d, got %d", test.args, test.code, code)
+		}
+		if out.String() != test.out {
+			t.Errorf("%v: expected output %q, got %q", test.args, test.out, out.String())
+		}
+	}
+}
+
+func TestRunList(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "words.txt")
+	if err := os.WriteFile(path, []byte("bananas are\nyellow\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		args  []string
+		piped string
+		out   string
+	}{
+		{[]string{"-list"}, "", "feed\nthe\nmonkey\n"},
+		{[]string{"-list"}, "eat your greens", "eat\nyour\ngreens\n"},
+		{[]string{"-list", "-file=" + path}, "eat your greens", "bananas\nare\nyellow\n"},
+	}
+	for _, test := range tests {
+		out, _ := redirect(t)
+		if test.piped != "" {
+			r, w, err := os.Pipe()
+			if err != nil {
+				t.Fatal(err)
+			}
+			go func() {
+				io.WriteString(w, test.piped)
+				w.Close()
+			}()
+			defer r.Close()
+			stdin = r
+		}
+		if code := run(test.args); code != exitOK {
+			t.Errorf("%v: expected exit code %d, got %d", test.args, exitOK, code)
+		}
+		if out.String() != test.out {
+			t.Errorf("%v piped %q: expected %q, got %q", test.args, test.piped, test.out, out.String())
+		}
+	}
+}
+
+func TestRunDuration(t *testing.T) {
+	out, errOut := redirect(t)
+	started := time.Now()
+	code := run([]string{"-duration=50ms", "-delay=1ms", "-count=100000"})
+	elapsed := time.Since(started)
+	if code != exitOK {
+		t.Fatalf("expected exit code %d, got %d", exitOK, code)
+	}
+	if elapsed < 50*time.Millisecond || elapsed > time.Second {
+		t.Errorf("expected the run to stop after about 50ms, took %s", elapsed)
+	}
+	n := strings.Count(out.String(), "\n")
+	if n == 0 {
+		t.Fatal("expected some words before the deadline")
+	}
+	if line := fmt.Sprintf("stopped after 50ms with %d words\n", n); !strings.Contains(errOut.String(), line) {
+		t.Errorf("expected %q on stderr, got:\n%s", line, errOut.String())
+	}
+}
+
+func TestRunInterrupted(t *testing.T) {
+	// keep the test process alive should the signal arrive outside run
+	signalChannel := make(chan os.Signal, 1)
+	signal.Notify(signalChannel, os.Interrupt)
+	defer signal.Stop(signalChannel)
+
+	out, errOut := redirect(t)
+	code := make(chan int)
+	go func() {
+		code <- run([]string{"-count=1000", "-delay=5ms"})
+	}()
+	// run installs its handler before it writes the first word
+	for out.String() == "" {
+		time.Sleep(time.Millisecond)
+	}
+	if err := syscall.Kill(os.Getpid(), syscall.SIGINT); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case c := <-code:
+		if c != exitInterrupted {
+			t.Fatalf("expected exit code %d, got %d", exitInterrupted, c)
+		}
+	case <-time.After(testTimeout):
+		t.Fatal("run did not return after SIGINT")
+	}
+	if !strings.Contains(errOut.String(), "interrupted after") {
+		t.Fatalf("expected an interruption notice, got %q", errOut.String())
+	}
+}
+
+// testEmit checks that Emit sends want for items, and nothing more if items
+// is empty.
+func testEmit[T comparable](t *testing.T, items, want []T) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	itemChannel := Emit(ctx, items, 0, 0)
+	got := make([]T, 0, len(want))
+	for len(got) < len(want) {
+		item, ok := <-itemChannel
+		if !ok {
+			break
+		}
+		got = append(got, item)
+	}
+	if !slices.Equal(got, want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+	if len(items) == 0 {
+		if item, ok := <-itemChannel; ok {
+			t.Fatalf("expected no items, got %v", item)
+		}
+	}
+	cancel()
+	for range itemChannel {
+	}
+}
+
+func TestEmit(t *testing.T) {
+	type point struct{ x, y int }
+	tests := []struct {
+		name string
+		test func(*testing.T)
+	}{
+		{"string", func(t *testing.T) {
+			testEmit(t, defaultWords, []string{"feed", "the", "monkey", "feed", "the"})
+		}},
+		{"int", func(t *testing.T) {
+			testEmit(t, []int{1, 2}, []int{1, 2, 1, 2, 1})
+		}},
+		{"struct", func(t *testing.T) {
+			testEmit(t, []point{{1, 2}, {3, 4}, {5, 6}}, []point{{1, 2}, {3, 4}, {5, 6}, {1, 2}})
+		}},
+		{"empty", func(t *testing.T) {
+			testEmit(t, []point{}, []point{})
+		}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, test.test)
+	}
+}
+
+func TestEmitRoundRobin(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	wordChannel := EmitRoundRobin(ctx, []string{"monkeys", "cats"}, nil, []string{"eat", "sleep", "play"})
+	got := Collect(wordChannel, 12)
+	want := []string{
+		"monkeys", "eat", "cats", "sleep",
+		"monkeys", "play", "cats", "eat",
+		"monkeys", "sleep", "cats", "play",
+	}
+	if !slices.Equal(got, want) {
+		t.Fatalf("expected %q, got %q", want, got)
+	}
+	cancel()
+	for range wordChannel {
+	}
+
+	if words := Collect(EmitRoundRobin(ctx, nil, []string{}), 1); len(words) != 0 {
+		t.Errorf("expected no words from empty lists, got %q", words)
+	}
+}
+
+func ExampleEmit() {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	words := Emit(ctx, []string{"feed", "the", "monkey"}, 0, 0)
+	n := 0
+	for word := range words {
+		fmt.Println(word)
+		if n++; n == 4 {
+			break
+		}
+	}
+	cancel()
+	// cancelling closes the channel, which ends this loop
+	for range words {
+	}
+	// Output:
+	// feed
+	// the
+	// monkey
+	// feed
+}
+
+func TestEmitNegativeBuffer(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	wordChannel := Emit(ctx, defaultWords, -1, 0)
+	if size := cap(wordChannel); size != 0 {
+		t.Fatalf("expected an unbuffered channel, got a buffer of %d", size)
+	}
+	if got := receive(t, wordChannel, 3); !slices.Equal(got, defaultWords) {
+		t.Fatalf("expected %q, got %q", defaultWords, got)
+	}
+	cancel()
+	waitClosed(t, wordChannel)
+}
+
+func TestEmitCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	wordChannel := Emit(ctx, defaultWords, 0, 0)
+	receive(t, wordChannel, 4)
+	cancel()
+	// the goroutine returns and closes the channel without further reads
+	waitClosed(t, wordChannel)
+}
+
+func BenchmarkEmit(b *testing.B) {
+	for _, buffer := range []int{0, 1, 16, 128} {
+		b.Run(fmt.Sprintf("buffer=%d", buffer), func(b *testing.B) {
+			emitter := NewEmitter(defaultWords)
+			emitter.Buffer = buffer
+			wordChannel, err := emitter.Start()
+			if err != nil {
+				b.Fatal(err)
+			}
+			// stop the goroutine so repeated runs don't pile up emitters
+			defer func() {
+				emitter.Stop()
+				emitter.Wait()
+			}()
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				<-wordChannel
+			}
+		})
+	}
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+)
+
+// fanOutBuffer is how many words a FanOut consumer may fall behind the
+// fastest one before it holds up the stream, and fanOutTimeout how long it
+// may hold it up before it is cut off.
+const (
+	fanOutBuffer  = 16
+	fanOutTimeout = 100 * time.Millisecond
+)
+
+// FanOut copies every word read from in to each of n returned channels. All
+// channels are closed once in is closed or ctx is cancelled.
+//
+// Every output channel is buffered with fanOutBuffer words, so a slow
+// consumer does not hold up the others until it falls that far behind.
+// Beyond that the stream advances at the pace of the slowest consumer, but a
+// consumer that leaves a word untaken for fanOutTimeout has its channel
+// closed, and the others carry on without it. A consumer never misses a word
+// before its channel is closed.
+//
+// FanOut returns no channels and leaves in alone if n is zero or less.
+func FanOut(ctx context.Context, in <-chan string, n int) []<-chan string {
+	if n <= 0 {
+		return nil
+	}
+	outChannels := make([]chan string, n)
+	result := make([]<-chan string, n)
+	for i := range outChannels {
+		outChannels[i] = make(chan string, fanOutBuffer)
+		result[i] = outChannels[i]
+	}
+	go func() {
+		// the channels of the consumers not cut off yet
+		active := slices.Clone(outChannels)
+		defer func() {
+			for _, out := range active {
+				close(out)
+			}
+		}()
+		for {
+			var word string
+			var ok bool
+			select {
+			case word, ok = <-in:
+				if !ok {
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+			for i := 0; i < len(active); i++ {
+				out := active[i]
+				select {
+				case out <- word:
+					continue
+				default:
+				}
+				timer := time.NewTimer(fanOutTimeout)
+				select {
+				case out <- word:
+				case <-timer.C:
+					close(out)
+					active = slices.Delete(active, i, i+1)
+					i--
+				case <-ctx.Done():
+					timer.Stop()
+					return
+				}
+				timer.Stop()
+			}
+		}
+	}()
+	return result
+}
+
+// Merge forwards the words from all chans to a single channel, which is
+// closed once every input is closed or ctx is cancelled.
+func Merge(ctx context.Context, chans ...<-chan string) <-chan string {
+	out := make(chan string)
+	var wg sync.WaitGroup
+	wg.Add(len(chans))
+	for _, in := range chans {
+		go func(in <-chan string) {
+			defer wg.Done()
+			for {
+				select {
+				case word, ok := <-in:
+					if !ok {
+						return
+					}
+					select {
+					case out <- word:
+					case <-ctx.Done():
+						return
+					}
+				case <-ctx.Done():
+					return
+				}
+			}
+		}(in)
+	}
+	go func() {
+		wg.Wait()
+		close(out)
+	}()
+	return out
+}
+
+// Transform sends fn applied to every word read from in. The returned channel
+// is closed once in is closed.
+func Transform(in <-chan string, fn func(string) string) <-chan string {
+	out := make(chan string)
+	go func() {
+		defer close(out)
+		for word := range in {
+			out <- fn(word)
+		}
+	}()
+	return out
+}
+
+// Dedupe forwards the words read from in, dropping any word equal to the one
+// just before it. The returned channel is closed once in is closed.
+func Dedupe(in <-chan string) <-chan string {
+	out := make(chan string)
+	go func() {
+		defer close(out)
+		first, previous := true, ""
+		for word := range in {
+			if first || word != previous {
+				out <- word
+			}
+			first, previous = false, word
+		}
+	}()
+	return out
+}
+
+// Collect reads up to n words from wordChannel and returns them. It returns
+// fewer words if wordChannel is closed first, and none if n is negative.
+func Collect(wordChannel <-chan string, n int) []string {
+	n = max(n, 0)
+	words := make([]string, 0, n)
+	for len(words) < n {
+		word, ok := <-wordChannel
+		if !ok {
+			break
+		}
+		words = append(words, word)
+	}
+	return words
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// feed sends words on a channel that is closed after the last one.
+func feed(words ...string) <-chan string {
+	wordChannel := make(chan string)
+	go func() {
+		defer close(wordChannel)
+		for _, word := range words {
+			wordChannel <- word
+		}
+	}()
+	return wordChannel
+}
+
+func TestFanOut(t *testing.T) {
+	words := []string{"feed", "the", "monkey", "feed", "the", "monkey", "bananas"}
+	outs := FanOut(context.Background(), feed(words...), 2)
+	if len(outs) != 2 {
+		t.Fatalf("expected 2 channels, got %d", len(outs))
+	}
+	got := make([][]string, len(outs))
+	var wg sync.WaitGroup
+	for i, out := range outs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for word := range out {
+				got[i] = append(got[i], word)
+			}
+		}()
+	}
+	wg.Wait()
+	for i := range got {
+		if !slices.Equal(got[i], words) {
+			t.Errorf("consumer %d: expected %q, got %q", i, words, got[i])
+		}
+	}
+
+	if outs := FanOut(context.Background(), feed(), -1); len(outs) != 0 {
+		t.Errorf("expected no channels for a negative count, got %d", len(outs))
+	}
+}
+
+func TestFanOutStalled(t *testing.T) {
+	words := make([]string, 100)
+	for i := range words {
+		words[i] = defaultWords[i%3]
+	}
+	outs := FanOut(context.Background(), feed(words...), 2)
+	// the first consumer never reads, yet the second gets every word
+	if got := Collect(outs[1], 200); !slices.Equal(got, words) {
+		t.Fatalf("expected %d words, got %d", len(words), len(got))
+	}
+	// the stalled consumer was cut off after the words it had room for
+	if got := Collect(outs[0], 200); !slices.Equal(got, words[:fanOutBuffer]) {
+		t.Fatalf("expected the first %d words, got %q", fanOutBuffer, got)
+	}
+}
+
+func TestFanOutCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	// in is never closed, so only cancelling ends FanOut
+	outs := FanOut(ctx, make(chan string), 3)
+	cancel()
+	for _, out := range outs {
+		waitClosed(t, out)
+	}
+}
+
+func TestMerge(t *testing.T) {
+	got := Collect(Merge(context.Background(), feed("feed", "the", "monkey"), feed("eat", "your", "greens", "now")), 10)
+	slices.Sort(got)
+	want := []string{"eat", "feed", "greens", "monkey", "now", "the", "your"}
+	if !slices.Equal(got, want) {
+		t.Fatalf("expected %q, got %q", want, got)
+	}
+}
+
+func TestMergeCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	// neither input is ever closed, so only cancelling ends Merge
+	out := Merge(ctx, make(chan string), feed("feed", "the", "monkey"))
+	cancel()
+	waitClosed(t, out)
+}
+
+func TestTransform(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	upper := Transform(Emit(ctx, defaultWords, 0, 0), strings.ToUpper)
+	got := Collect(upper, 5)
+	want := []string{"FEED", "THE", "MONKEY", "FEED", "THE"}
+	if !slices.Equal(got, want) {
+		t.Fatalf("expected %q, got %q", want, got)
+	}
+	// closing the emitted channel closes the transformed one
+	cancel()
+	waitClosed(t, upper)
+}
+
+func TestDedupe(t *testing.T) {
+	in := feed("", "", "feed", "the", "the", "the", "monkey", "feed", "feed")
+	var got []string
+	for word := range Dedupe(in) {
+		got = append(got, word)
+	}
+	want := []string{"", "feed", "the", "monkey", "feed"}
+	if !slices.Equal(got, want) {
+		t.Fatalf("expected %q, got %q", want, got)
+	}
+}
+
+func TestCollect(t *testing.T) {
+	tests := []struct {
+		in  []string
+		n   int
+		out []string
+	}{
+		{defaultWords, 2, []string{"feed", "the"}},
+		{defaultWords, 3, defaultWords},
+		{defaultWords, 5, defaultWords},
+		{defaultWords, 0, []string{}},
+		{defaultWords, -1, []string{}},
+		{nil, 2, []string{}},
+	}
+	for _, test := range tests {
+		got := Collect(feed(test.in...), test.n)
+		if !slices.Equal(got, test.out) {
+			t.Errorf("%v, %d: expected %q, got %q", test.in, test.n, test.out, got)
+		}
+	}
+}
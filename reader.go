@@ -0,0 +1,34 @@
+package main
+
+import (
+	"context"
+	"io"
+)
+
+// wordReader serves the words of a word channel as newline terminated bytes.
+type wordReader struct {
+	wordChannel <-chan string
+	pending     []byte
+}
+
+// NewReader returns a reader over words cycled by Emit, each followed by a
+// newline. Read returns io.EOF once ctx is cancelled.
+func NewReader(ctx context.Context, words []string) io.Reader {
+	return &wordReader{wordChannel: Emit(ctx, words, 0, 0)}
+}
+
+func (r *wordReader) Read(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	if len(r.pending) == 0 {
+		word, ok := <-r.wordChannel
+		if !ok {
+			return 0, io.EOF
+		}
+		r.pending = append(append(r.pending[:0], word...), '\n')
+	}
+	n := copy(p, r.pending)
+	r.pending = r.pending[n:]
+	return n, nil
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"testing"
+	"testing/iotest"
+)
+
+func TestNewReader(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	want := "feed\nthe\nmonkey\nfeed\n"
+	var buf bytes.Buffer
+	// a one byte reader forces every word to be split across reads
+	r := iotest.OneByteReader(NewReader(ctx, defaultWords))
+	if _, err := io.CopyN(&buf, r, int64(len(want))); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != want {
+		t.Fatalf("expected %q, got %q", want, buf.String())
+	}
+}
+
+func TestNewReaderCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	r := NewReader(ctx, defaultWords)
+	cancel()
+	// a word may already be in flight, but the reader must end with io.EOF
+	if _, err := io.Copy(io.Discard, r); err != nil {
+		t.Fatalf("expected io.EOF to end the copy, got %v", err)
+	}
+}
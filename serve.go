@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+)
+
+// streamHandler serves every request its own emitter, created by newEmitter
+// with the request context, and sends each word as a server-sent event. The
+// emitter stops when the client disconnects.
+func streamHandler(newEmitter func(context.Context) *Emitter) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+			return
+		}
+		emitter := newEmitter(r.Context())
+		wordChannel, err := emitter.Start()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer func() {
+			emitter.Stop()
+			emitter.Wait()
+		}()
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		for word := range wordChannel {
+			if _, err := fmt.Fprintf(w, "data: %s\n\n", word); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	})
+}
+
+// newServeMux routes /stream to streamHandler.
+func newServeMux(newEmitter func(context.Context) *Emitter) *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.Handle("/stream", streamHandler(newEmitter))
+	return mux
+}
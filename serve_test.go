@@ -0,0 +1,43 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+)
+
+func TestStreamHandler(t *testing.T) {
+	server := httptest.NewServer(newServeMux(func(ctx context.Context) *Emitter {
+		return NewEmitterWithContext(ctx, defaultWords)
+	}))
+	// Close waits for the handlers, so a leaked emitter hangs the test
+	defer server.Close()
+
+	readEvents := func() []string {
+		resp, err := http.Get(server.URL + "/stream")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+			t.Fatalf("unexpected content type %q", ct)
+		}
+		var lines []string
+		scanner := bufio.NewScanner(resp.Body)
+		for len(lines) < 8 && scanner.Scan() {
+			lines = append(lines, scanner.Text())
+		}
+		return lines
+	}
+
+	want := []string{"data: feed", "", "data: the", "", "data: monkey", "", "data: feed", ""}
+	// every client gets its own stream starting from the first word
+	for i := 0; i < 2; i++ {
+		if got := readEvents(); !slices.Equal(got, want) {
+			t.Fatalf("expected %q, got %q", want, got)
+		}
+	}
+}
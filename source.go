@@ -0,0 +1,113 @@
+package main
+
+import (
+	"math/rand"
+	"sort"
+)
+
+// Source provides the words for an Emitter.
+type Source interface {
+	// Next returns the next word, or false once the source has run out.
+	Next() (string, bool)
+}
+
+// SliceSource cycles through a slice in order.
+type SliceSource[T any] struct {
+	items     []T
+	cycles    int
+	i, passes int
+}
+
+// NewSliceSource returns a SliceSource that runs out after cycles passes over
+// items. Zero or less cycles never runs out, unless items is empty.
+func NewSliceSource[T any](items []T, cycles int) *SliceSource[T] {
+	return &SliceSource[T]{items: items, cycles: cycles}
+}
+
+func (s *SliceSource[T]) Next() (T, bool) {
+	if len(s.items) == 0 || (s.cycles > 0 && s.passes == s.cycles) {
+		var zero T
+		return zero, false
+	}
+	item := s.items[s.i]
+	s.i++
+	if s.i == len(s.items) {
+		s.i = 0
+		s.passes++
+	}
+	return item, true
+}
+
+// RandomSource picks every item at random from a slice. It draws from its own
+// rand.Rand, so sources created with the same seed yield the same sequence.
+type RandomSource[T any] struct {
+	items     []T
+	rng       *rand.Rand
+	remaining int
+}
+
+// NewRandomSource returns a RandomSource seeded with seed that runs out after
+// picking cycles times as many items as there are in items. Zero or less
+// cycles never runs out, unless items is empty.
+func NewRandomSource[T any](items []T, seed int64, cycles int) *RandomSource[T] {
+	remaining := -1
+	if cycles > 0 {
+		remaining = cycles * len(items)
+	}
+	return &RandomSource[T]{items: items, rng: rand.New(rand.NewSource(seed)), remaining: remaining}
+}
+
+func (s *RandomSource[T]) Next() (T, bool) {
+	if len(s.items) == 0 || s.remaining == 0 {
+		var zero T
+		return zero, false
+	}
+	if s.remaining > 0 {
+		s.remaining--
+	}
+	return s.items[s.rng.Intn(len(s.items))], true
+}
+
+// WeightedSource picks every item at random from a slice, in proportion to
+// its weight. Picking searches the cumulative weights, so large weights cost
+// nothing extra.
+type WeightedSource[T any] struct {
+	items      []T
+	cumulative []int64
+	rng        *rand.Rand
+	remaining  int
+}
+
+// NewWeightedSource returns a WeightedSource seeded with seed, where
+// weights[i] is the weight of items[i]. Items without a positive weight are
+// never picked. Like a RandomSource it runs out after picking cycles times as
+// many items as there are in items, or never for zero or less cycles.
+func NewWeightedSource[T any](items []T, weights []int, seed int64, cycles int) *WeightedSource[T] {
+	cumulative := make([]int64, len(items))
+	var total int64
+	for i := range items {
+		if i < len(weights) && weights[i] > 0 {
+			total += int64(weights[i])
+		}
+		cumulative[i] = total
+	}
+	remaining := -1
+	if cycles > 0 {
+		remaining = cycles * len(items)
+	}
+	return &WeightedSource[T]{items: items, cumulative: cumulative, rng: rand.New(rand.NewSource(seed)), remaining: remaining}
+}
+
+func (s *WeightedSource[T]) Next() (T, bool) {
+	if len(s.items) == 0 || s.cumulative[len(s.items)-1] == 0 || s.remaining == 0 {
+		var zero T
+		return zero, false
+	}
+	if s.remaining > 0 {
+		s.remaining--
+	}
+	r := s.rng.Int63n(s.cumulative[len(s.items)-1])
+	// the first item whose cumulative weight exceeds r
+	i := sort.Search(len(s.cumulative), func(i int) bool { return s.cumulative[i] > r })
+	return s.items[i], true
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+// next reads up to n items from source, stopping early once it runs out.
+func next[T any](source interface{ Next() (T, bool) }, n int) []T {
+	var items []T
+	for len(items) < n {
+		item, ok := source.Next()
+		if !ok {
+			break
+		}
+		items = append(items, item)
+	}
+	return items
+}
+
+func TestSliceSource(t *testing.T) {
+	tests := []struct {
+		items  []string
+		cycles int
+		out    []string
+	}{
+		{defaultWords, 0, []string{"feed", "the", "monkey", "feed", "the", "monkey", "feed"}},
+		{defaultWords, 2, []string{"feed", "the", "monkey", "feed", "the", "monkey"}},
+		{[]string{"feed"}, 0, []string{"feed", "feed", "feed", "feed", "feed", "feed", "feed"}},
+		{nil, 0, nil},
+	}
+	for _, test := range tests {
+		got := next[string](NewSliceSource(test.items, test.cycles), 7)
+		if !slices.Equal(got, test.out) {
+			t.Errorf("%v with %d cycles: expected %v, got %v", test.items, test.cycles, test.out, got)
+		}
+	}
+}
+
+func TestRandomSource(t *testing.T) {
+	first := next[string](NewRandomSource(defaultWords, 42, 0), 30)
+	second := next[string](NewRandomSource(defaultWords, 42, 0), 30)
+	if !slices.Equal(first, second) {
+		t.Fatalf("expected the same sequence for the same seed, got %v and %v", first, second)
+	}
+	for _, word := range first {
+		if !slices.Contains(defaultWords, word) {
+			t.Fatalf("unexpected word %q", word)
+		}
+	}
+	if got := next[string](NewRandomSource(defaultWords, 42, 2), 30); len(got) != 6 {
+		t.Fatalf("expected 2 cycles of 3 words, got %v", got)
+	}
+	if _, ok := NewRandomSource[string](nil, 42, 0).Next(); ok {
+		t.Fatal("expected an empty source to run out")
+	}
+}
+
+func TestWeightedSource(t *testing.T) {
+	weights := []int{1, 5, 2}
+	const draws = 8000
+	counts := map[string]int{}
+	for _, word := range next[string](NewWeightedSource(defaultWords, weights, 7, 0), draws) {
+		counts[word]++
+	}
+	for i, word := range defaultWords {
+		want := float64(weights[i]) / 8
+		got := float64(counts[word]) / draws
+		if got < want-0.02 || got > want+0.02 {
+			t.Errorf("%s: expected a share of %.3f, got %.3f", word, want, got)
+		}
+	}
+
+	// items without weight are never picked
+	for _, word := range next[string](NewWeightedSource(defaultWords, []int{0, 1, 0}, 7, 0), 100) {
+		if word != "the" {
+			t.Fatalf("expected only the, got %q", word)
+		}
+	}
+	if _, ok := NewWeightedSource(defaultWords, []int{0, 0, 0}, 7, 0).Next(); ok {
+		t.Fatal("expected a source without weights to run out")
+	}
+}